@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProviderAdapter lets a single Terraform resource type plug into address
+// and tag resolution without the generic Resource/Address/Tags logic having
+// to know about it. Register implementations from init() in their own file
+// (see adapter_aws.go and friends) so adding a provider touches one new file
+// rather than every switch statement in this package.
+type ProviderAdapter interface {
+
+	// AddressKeys returns the attribute paths (dotted, as used by
+	// Resource.attr) checked in order for this resource's address, once
+	// ResolveAddress has had a chance to produce one of its own.
+	AddressKeys() []string
+
+	// ExtractTags returns the arbitrary key/value pairs attached to a
+	// resource of this type, given its flattened attribute map.
+	ExtractTags(attrs map[string]string) map[string]string
+
+	// ResolveAddress returns this resource's address for providers that
+	// can't be satisfied by a plain AddressKeys lookup, such as ones that
+	// need to follow a reference to another resource (a NIC, a public IP).
+	// index, when non-nil, holds other resources known from the same
+	// statefile; Resource.Address passes nil, so adapters that follow a
+	// reference do so through carrierIndex. Return "" to fall through to
+	// AddressKeys.
+	ResolveAddress(r *Resource, index map[string]*Resource) string
+}
+
+// providerAdapters holds the registered adapter for each Terraform resource
+// type we support. Resource types with no entry here have no tags, and no
+// address unless TF_KEY_NAME names one (see Resource.Address).
+var providerAdapters = map[string]ProviderAdapter{}
+
+// RegisterProviderAdapter makes an adapter available for the given
+// Terraform resource type. Called from init() in each provider's own file;
+// panics on a duplicate registration, since that can only be a programming
+// mistake.
+func RegisterProviderAdapter(resourceType string, adapter ProviderAdapter) {
+	if _, exists := providerAdapters[resourceType]; exists {
+		panic(fmt.Sprintf("provider adapter already registered for %s", resourceType))
+	}
+	providerAdapters[resourceType] = adapter
+}
+
+// noTags can be embedded by an adapter whose resource type doesn't carry
+// any arbitrary key/value metadata in Terraform.
+type noTags struct{}
+
+func (noTags) ExtractTags(attrs map[string]string) map[string]string {
+	return map[string]string{}
+}
+
+// noResolveAddress can be embedded by an adapter that's fully satisfied by
+// a plain AddressKeys lookup.
+type noResolveAddress struct{}
+
+func (noResolveAddress) ResolveAddress(r *Resource, index map[string]*Resource) string {
+	return ""
+}
+
+// tagContainer extracts the lowercased key/value pairs stored under a
+// map-valued attribute such as "tags" or "metadata" in a flattened
+// attribute map, e.g. {"tags.Name": "web"} -> {"name": "web"}.
+func tagContainer(attrs map[string]string, name string) map[string]string {
+	t := map[string]string{}
+
+	prefix := name + "."
+	for k, v := range attrs {
+		parts := strings.SplitN(k, ".", 2)
+		// At some point Terraform changed the key for counts of attributes to end with ".%"
+		// instead of ".#". Both need to be considered as Terraform still supports state
+		// files using the old format.
+		if len(parts) == 2 && parts[0]+"." == prefix && parts[1] != "#" && parts[1] != "%" {
+			t[strings.ToLower(parts[1])] = strings.ToLower(v)
+		}
+	}
+	return t
+}
+
+// tagSet extracts the lowercased members of a list/set-valued attribute
+// such as a digitalocean_droplet "tags" list from a flattened attribute map.
+func tagSet(attrs map[string]string, name string) map[string]string {
+	t := map[string]string{}
+
+	prefix := name + "."
+	for k, v := range attrs {
+		parts := strings.SplitN(k, ".", 2)
+		if len(parts) == 2 && parts[0]+"." == prefix && parts[1] != "#" {
+			t[strings.ToLower(v)] = ""
+		}
+	}
+	return t
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("aws_instance", awsInstanceAdapter{})
+}
+
+type awsInstanceAdapter struct {
+	noResolveAddress
+}
+
+func (awsInstanceAdapter) AddressKeys() []string {
+	return []string{"public_ip", "private_ip"}
+}
+
+func (awsInstanceAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "tags")
+}
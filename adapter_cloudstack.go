@@ -0,0 +1,14 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("cloudstack_instance", cloudstackInstanceAdapter{})
+}
+
+type cloudstackInstanceAdapter struct {
+	noResolveAddress
+	noTags
+}
+
+func (cloudstackInstanceAdapter) AddressKeys() []string {
+	return []string{"ipaddress"}
+}
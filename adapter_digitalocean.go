@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("digitalocean_droplet", digitaloceanDropletAdapter{})
+}
+
+type digitaloceanDropletAdapter struct {
+	noResolveAddress
+}
+
+func (digitaloceanDropletAdapter) AddressKeys() []string {
+	return []string{"ipv4_address"}
+}
+
+func (digitaloceanDropletAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
@@ -0,0 +1,14 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("docker_container", dockerContainerAdapter{})
+}
+
+type dockerContainerAdapter struct {
+	noResolveAddress
+	noTags
+}
+
+func (dockerContainerAdapter) AddressKeys() []string {
+	return []string{"ip_address"}
+}
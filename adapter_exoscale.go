@@ -0,0 +1,14 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("exoscale_compute", exoscaleComputeAdapter{})
+}
+
+type exoscaleComputeAdapter struct {
+	noResolveAddress
+	noTags
+}
+
+func (exoscaleComputeAdapter) AddressKeys() []string {
+	return []string{"networks.0.ip4address"}
+}
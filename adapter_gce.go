@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+func init() {
+	RegisterProviderAdapter("google_compute_instance", googleComputeInstanceAdapter{})
+}
+
+// googleComputeInstanceAdapter resolves google_compute_instance addresses by
+// walking every network_interface block (not just the first, as older
+// versions of this tool did) and falling back to an alias IP range if none
+// of them has a usable address of its own.
+type googleComputeInstanceAdapter struct{}
+
+func (googleComputeInstanceAdapter) AddressKeys() []string {
+	return nil
+}
+
+func (googleComputeInstanceAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
+
+func (googleComputeInstanceAdapter) ResolveAddress(r *Resource, index map[string]*Resource) string {
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("network_interface.%d.", i)
+
+		name := r.attr(prefix + "name")
+		natIp := r.attr(prefix + "access_config.0.nat_ip")
+		assignedNatIp := r.attr(prefix + "access_config.0.assigned_nat_ip")
+		address := r.attr(prefix + "address")
+
+		if name == "" && natIp == "" && assignedNatIp == "" && address == "" {
+			break
+		}
+
+		switch {
+		case natIp != "":
+			return natIp
+		case assignedNatIp != "":
+			return assignedNatIp
+		case address != "":
+			return address
+		}
+	}
+
+	// No network_interface had a usable address; fall back to the first
+	// alias IP range, for instances only reachable via an internal alias.
+	return r.attr("network_interface.0.alias_ip_range.0.ip_cidr_range")
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("hcloud_server", hcloudServerAdapter{})
+}
+
+type hcloudServerAdapter struct {
+	noResolveAddress
+}
+
+func (hcloudServerAdapter) AddressKeys() []string {
+	return []string{"ipv4_address"}
+}
+
+func (hcloudServerAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "labels")
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("linode_instance", linodeInstanceAdapter{})
+}
+
+type linodeInstanceAdapter struct {
+	noResolveAddress
+}
+
+func (linodeInstanceAdapter) AddressKeys() []string {
+	return []string{"ip_address"}
+}
+
+func (linodeInstanceAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
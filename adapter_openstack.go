@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("openstack_compute_instance_v2", openstackComputeInstanceV2Adapter{})
+}
+
+type openstackComputeInstanceV2Adapter struct {
+	noResolveAddress
+}
+
+func (openstackComputeInstanceV2Adapter) AddressKeys() []string {
+	return []string{"access_ip_v4", "floating_ip"}
+}
+
+func (openstackComputeInstanceV2Adapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "metadata")
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("packet_device", packetDeviceAdapter{})
+}
+
+type packetDeviceAdapter struct {
+	noResolveAddress
+}
+
+func (packetDeviceAdapter) AddressKeys() []string {
+	return []string{"access_public_ipv4", "network.0.address"}
+}
+
+func (packetDeviceAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
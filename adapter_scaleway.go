@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("scaleway_server", scalewayServerAdapter{})
+}
+
+type scalewayServerAdapter struct {
+	noResolveAddress
+}
+
+func (scalewayServerAdapter) AddressKeys() []string {
+	return []string{"public_ip", "public_ipv6"}
+}
+
+func (scalewayServerAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
@@ -0,0 +1,14 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("softlayer_virtual_guest", softlayerVirtualGuestAdapter{})
+}
+
+type softlayerVirtualGuestAdapter struct {
+	noResolveAddress
+	noTags
+}
+
+func (softlayerVirtualGuestAdapter) AddressKeys() []string {
+	return []string{"ipv4_address", "ipv4_address_private"}
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("triton_machine", tritonMachineAdapter{})
+}
+
+type tritonMachineAdapter struct {
+	noResolveAddress
+}
+
+func (tritonMachineAdapter) AddressKeys() []string {
+	return []string{"primaryip"}
+}
+
+func (tritonMachineAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "tags")
+}
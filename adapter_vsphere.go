@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("vsphere_virtual_machine", vsphereVirtualMachineAdapter{})
+}
+
+type vsphereVirtualMachineAdapter struct {
+	noResolveAddress
+}
+
+func (vsphereVirtualMachineAdapter) AddressKeys() []string {
+	return []string{"ip_address", "network_interface.0.ipv4_address", "default_ip_address"}
+}
+
+func (vsphereVirtualMachineAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "custom_configuration_parameters")
+}
@@ -0,0 +1,17 @@
+package main
+
+func init() {
+	RegisterProviderAdapter("vultr_instance", vultrInstanceAdapter{})
+}
+
+type vultrInstanceAdapter struct {
+	noResolveAddress
+}
+
+func (vultrInstanceAdapter) AddressKeys() []string {
+	return []string{"main_ip"}
+}
+
+func (vultrInstanceAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagSet(attrs, "tags")
+}
@@ -0,0 +1,153 @@
+package main
+
+// associationIndex indexes "carrier" resources - resources that hold an
+// address (or a reference to one) on behalf of some other resource, such as
+// a NIC, a public IP, an EIP, or a load balancer backend pool - keyed by
+// their Terraform id. A compute resource resolves an address, or an
+// alternate address exposed under a synthetic group, by walking a chain of
+// references through it: e.g. VM -> NIC -> public IP resource -> address,
+// or VM -> NIC -> backend pool -> gateway frontend address.
+//
+// This generalizes what used to be a single Azure-only map
+// (azureNICPrimaryIps) into something every provider's carrier resources
+// can share.
+type associationIndex struct {
+	carriers map[string]*carrierEntry
+}
+
+// carrierEntry holds what's known about a single carrier: addresses it
+// holds directly, keyed by scope (e.g. "private", "public", "gateway_ip"),
+// and ids it references onward, keyed by the attribute the reference came
+// from (e.g. "public_ip_address_id", "allocation_id").
+type carrierEntry struct {
+	addresses map[string][]string
+	refs      map[string][]string
+}
+
+// carrierIndex is the package-wide association index. Every provider's
+// "store" functions (AzureStoreNic, AWSStoreEip, ...) write into it during
+// pass 1 (as each resource is parsed); Resource.Address() and
+// Resource.AlternateAddresses() read from it during pass 2 (once every
+// resource is known).
+var carrierIndex = newAssociationIndex()
+
+func newAssociationIndex() *associationIndex {
+	return &associationIndex{carriers: map[string]*carrierEntry{}}
+}
+
+func (idx *associationIndex) entry(id string) *carrierEntry {
+	c, ok := idx.carriers[id]
+	if !ok {
+		c = &carrierEntry{addresses: map[string][]string{}, refs: map[string][]string{}}
+		idx.carriers[id] = c
+	}
+	return c
+}
+
+// storeAddress records that the carrier identified by id holds address
+// under scope.
+func (idx *associationIndex) storeAddress(id, scope, address string) {
+	if id == "" || address == "" {
+		return
+	}
+	idx.entry(id).addresses[scope] = append(idx.entry(id).addresses[scope], address)
+}
+
+// storeRef records that the carrier identified by id references targetId
+// through refType (e.g. a NIC's "public_ip_address_id", or an EIP
+// association's "allocation_id").
+func (idx *associationIndex) storeRef(id, refType, targetId string) {
+	if id == "" || targetId == "" {
+		return
+	}
+	idx.entry(id).refs[refType] = append(idx.entry(id).refs[refType], targetId)
+}
+
+// resolve returns the first address of scope known directly for any of the
+// given carrier ids, in order.
+func (idx *associationIndex) resolve(scope string, ids ...string) string {
+	for _, id := range ids {
+		c, ok := idx.carriers[id]
+		if !ok {
+			continue
+		}
+		for _, addr := range c.addresses[scope] {
+			if addr != "" {
+				return addr
+			}
+		}
+	}
+	return ""
+}
+
+// firstRef returns the first id referenced by the carrier identified by id
+// through refType, or "" if there is none. It's the single-hop building
+// block resolveVia wraps; a caller that needs to walk more than one hop
+// (see Resource.AlternateAddresses' aws_instance -> target group ->
+// listener -> load balancer chain) calls it directly between hops instead
+// of a deeper resolveVia.
+func (idx *associationIndex) firstRef(id, refType string) string {
+	c, ok := idx.carriers[id]
+	if !ok {
+		return ""
+	}
+	for _, ref := range c.refs[refType] {
+		if ref != "" {
+			return ref
+		}
+	}
+	return ""
+}
+
+// storeCarrier is pass 1 of the two-pass resolver: called as each resource
+// is parsed (from NewResource and NewResourceV2), it feeds carrierIndex for
+// every resource type that either holds an address on behalf of another
+// resource or merely associates two other resources together. Resource
+// types that carry their own address directly (and so go through a
+// ProviderAdapter instead) are not listed here.
+func storeCarrier(resourceType string, attrs map[string]string) {
+	switch resourceType {
+	case azureNicResourceKey:
+		AzureStoreNic(attrs)
+	case azurePublicIpResourceKey:
+		AzureStorePublicIp(attrs)
+	case azureAppGatewayResourceKey:
+		AzureStoreAppGateway(attrs)
+	case awsEipResourceKey:
+		AWSStoreEip(attrs)
+	case awsEipAssociationResourceKey:
+		AWSStoreEipAssociation(attrs)
+	case awsLbResourceKey, awsAlbResourceKey:
+		AWSStoreLb(attrs)
+	case awsLbListenerResourceKey:
+		AWSStoreLbListener(attrs)
+	case awsLbTargetGroupAttachmentResourceKey:
+		AWSStoreLbTargetGroupAttachment(attrs)
+	case gceInstanceGroupResourceKey:
+		GCEStoreInstanceGroup(attrs)
+	case gceBackendServiceResourceKey:
+		GCEStoreBackendService(attrs)
+	case gceForwardingRuleResourceKey:
+		GCEStoreForwardingRule(attrs)
+	default:
+		if azureBackendPoolAssociationTypes[resourceType] {
+			AzureStoreBackendPoolAssociation(attrs)
+		}
+	}
+}
+
+// resolveVia follows id -> refType -> (another carrier) -> scope, one hop
+// deep, for each of the given ids in order. It's how, e.g., a VM finds its
+// backend pool's gateway address by way of its NIC's pool association.
+func (idx *associationIndex) resolveVia(scope, refType string, ids ...string) string {
+	for _, id := range ids {
+		c, ok := idx.carriers[id]
+		if !ok {
+			continue
+		}
+		if addr := idx.resolve(scope, c.refs[refType]...); addr != "" {
+			return addr
+		}
+	}
+	return ""
+}
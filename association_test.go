@@ -0,0 +1,123 @@
+package main
+
+import "testing"
+
+func TestAssociationIndexResolve(t *testing.T) {
+	idx := newAssociationIndex()
+	idx.storeAddress("nic1", privateScope, "10.0.0.4")
+	idx.storeAddress("nic1", publicScope, "1.2.3.4")
+
+	if got := idx.resolve(privateScope, "nic1"); got != "10.0.0.4" {
+		t.Errorf("resolve(private, nic1) = %q, want %q", got, "10.0.0.4")
+	}
+	if got := idx.resolve(publicScope, "nic1"); got != "1.2.3.4" {
+		t.Errorf("resolve(public, nic1) = %q, want %q", got, "1.2.3.4")
+	}
+	if got := idx.resolve(privateScope, "missing"); got != "" {
+		t.Errorf("resolve for an unknown carrier = %q, want \"\"", got)
+	}
+
+	// resolve checks ids in order and returns the first match.
+	if got := idx.resolve(privateScope, "missing", "nic1"); got != "10.0.0.4" {
+		t.Errorf("resolve(missing, nic1) = %q, want %q (first matching id)", got, "10.0.0.4")
+	}
+}
+
+func TestAssociationIndexResolveVia(t *testing.T) {
+	idx := newAssociationIndex()
+	idx.storeAddress("pip1", publicScope, "5.6.7.8")
+	idx.storeRef("nic1", "public_ip_address_id", "pip1")
+
+	if got := idx.resolveVia(publicScope, "public_ip_address_id", "nic1"); got != "5.6.7.8" {
+		t.Errorf("resolveVia = %q, want %q", got, "5.6.7.8")
+	}
+	if got := idx.resolveVia(publicScope, "public_ip_address_id", "nic-without-a-public-ip"); got != "" {
+		t.Errorf("resolveVia for a carrier with no such ref = %q, want \"\"", got)
+	}
+}
+
+func TestAssociationIndexFirstRef(t *testing.T) {
+	idx := newAssociationIndex()
+	idx.storeRef("i-1", "target_group_arn", "tg1")
+	idx.storeRef("i-1", "target_group_arn", "tg2")
+
+	if got := idx.firstRef("i-1", "target_group_arn"); got != "tg1" {
+		t.Errorf("firstRef = %q, want %q (first stored ref)", got, "tg1")
+	}
+	if got := idx.firstRef("i-1", "no-such-ref-type"); got != "" {
+		t.Errorf("firstRef for an unknown refType = %q, want \"\"", got)
+	}
+	if got := idx.firstRef("unknown-carrier", "target_group_arn"); got != "" {
+		t.Errorf("firstRef for an unknown carrier = %q, want \"\"", got)
+	}
+}
+
+// TestAWSLbAssociationChain exercises the two-hop instance -> target group
+// -> listener -> load balancer walk AlternateAddresses does for aws_instance,
+// independent of the order the three resources are parsed in.
+func TestAWSLbAssociationChain(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	AWSStoreLbTargetGroupAttachment(map[string]string{
+		awsTargetGroupAttachmentTargetIdKey: "i-1",
+		awsTargetGroupAttachmentArnKey:      "tg-arn",
+	})
+	AWSStoreLbListener(map[string]string{
+		awsLbListenerTargetGroupArnKey: "tg-arn",
+		awsLbListenerLbArnKey:          "lb-arn",
+	})
+	AWSStoreLb(map[string]string{
+		awsLbArnKey:     "lb-arn",
+		awsLbDnsNameKey: "my-lb.us-east-1.elb.amazonaws.com",
+	})
+
+	tgArn := carrierIndex.firstRef("i-1", awsTargetGroupRefType)
+	if tgArn != "tg-arn" {
+		t.Fatalf("firstRef(i-1, target_group_arn) = %q, want %q", tgArn, "tg-arn")
+	}
+
+	got := carrierIndex.resolveVia(AwsLbGroup, awsLbListenerRefType, tgArn)
+	if got != "my-lb.us-east-1.elb.amazonaws.com" {
+		t.Errorf("resolved lb address = %q, want %q", got, "my-lb.us-east-1.elb.amazonaws.com")
+	}
+}
+
+// TestGCEBackendServiceAssociationChain exercises the two-hop instance ->
+// instance group -> backend service -> forwarding rule walk
+// AlternateAddresses does for google_compute_instance, independent of the
+// order the three resources are parsed in. Every resource's id is given a
+// value distinct from its self_link, since that's what GCEStoreInstanceGroup
+// and GCEStoreBackendService key by - a test that reused the same string for
+// both would pass even if the chain were wired to the wrong attribute.
+func TestGCEBackendServiceAssociationChain(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	const instanceSelfLink = "https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/web"
+	const groupSelfLink = "https://www.googleapis.com/compute/v1/projects/p/zones/z/instanceGroups/group1"
+	const backendServiceSelfLink = "https://www.googleapis.com/compute/v1/projects/p/global/backendServices/backend1"
+
+	GCEStoreInstanceGroup(map[string]string{
+		resourceIdKey: "projects/p/zones/z/instanceGroups/group1",
+		"self_link":   groupSelfLink,
+		"instances.0": instanceSelfLink,
+	})
+	GCEStoreBackendService(map[string]string{
+		resourceIdKey:     "projects/p/global/backendServices/backend1",
+		"self_link":       backendServiceSelfLink,
+		"backend.0.group": groupSelfLink,
+	})
+	GCEStoreForwardingRule(map[string]string{
+		gceForwardingRuleBackendServiceKey: backendServiceSelfLink,
+		gceForwardingRuleIpAddressKey:      "9.9.9.9",
+	})
+
+	groupId := carrierIndex.firstRef(instanceSelfLink, gceInstanceGroupRefType)
+	if groupId != groupSelfLink {
+		t.Fatalf("firstRef(instance, instance_group) = %q, want %q", groupId, groupSelfLink)
+	}
+
+	got := carrierIndex.resolveVia(GatewayIPGroup, gceBackendServiceRefType, groupId)
+	if got != "9.9.9.9" {
+		t.Errorf("resolved forwarding rule address = %q, want %q", got, "9.9.9.9")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+// AWS EIP related keys. aws_eip_association only links an instance to an
+// EIP (by allocation id) and has no address of its own; aws_eip carries the
+// actual public_ip.
+const awsEipResourceKey string = "aws_eip"
+const awsEipAssociationResourceKey string = "aws_eip_association"
+const awsEipPublicIpKey string = "public_ip"
+const awsEipAllocationIdKey string = "allocation_id"
+const awsEipAssocInstanceIdKey string = "instance_id"
+
+// awsEipRefType is the carrierIndex refType under which an aws_instance
+// records the EIP allocation id an aws_eip_association attached to it.
+const awsEipRefType string = "allocation_id"
+
+// AwsEipGroup is the synthetic Ansible group an aws_instance's associated
+// EIP address (see Resource.AlternateAddresses) is exposed under.
+const AwsEipGroup string = "eip"
+
+// AWSStoreEip records an aws_eip's public IP into carrierIndex, keyed both
+// by its own id and by its allocation_id (EC2-VPC EIPs are referenced by
+// aws_eip_association via the latter).
+func AWSStoreEip(attrs map[string]string) {
+	ip := attrs[awsEipPublicIpKey]
+	carrierIndex.storeAddress(attrs[resourceIdKey], publicScope, ip)
+	carrierIndex.storeAddress(attrs[awsEipAllocationIdKey], publicScope, ip)
+}
+
+// AWSStoreEipAssociation records that an instance is associated with an EIP
+// allocation, so the instance can resolve that EIP's address as an
+// alternate address the same way an Azure VM resolves through its NIC.
+func AWSStoreEipAssociation(attrs map[string]string) {
+	carrierIndex.storeRef(attrs[awsEipAssocInstanceIdKey], awsEipRefType, attrs[awsEipAllocationIdKey])
+}
@@ -0,0 +1,52 @@
+package main
+
+// AWS load balancer related keys. aws_lb_target_group_attachment only links
+// an instance to a target group (by arn); the target group itself carries
+// no address of its own - it's whichever aws_lb_listener forwards to it,
+// and that listener's aws_lb, that has the actual dns_name. aws_alb is an
+// older alias for the same resource as aws_lb.
+const awsLbResourceKey string = "aws_lb"
+const awsAlbResourceKey string = "aws_alb"
+const awsLbListenerResourceKey string = "aws_lb_listener"
+const awsLbTargetGroupAttachmentResourceKey string = "aws_lb_target_group_attachment"
+
+const awsLbArnKey string = "arn"
+const awsLbDnsNameKey string = "dns_name"
+const awsLbListenerLbArnKey string = "load_balancer_arn"
+const awsLbListenerTargetGroupArnKey string = "default_action.0.target_group_arn"
+const awsTargetGroupAttachmentTargetIdKey string = "target_id"
+const awsTargetGroupAttachmentArnKey string = "target_group_arn"
+
+// awsTargetGroupRefType is the carrierIndex refType under which an instance
+// records the target group arns its aws_lb_target_group_attachment(s)
+// reference.
+const awsTargetGroupRefType string = "target_group_arn"
+
+// awsLbListenerRefType is the carrierIndex refType under which a target
+// group's arn records the load balancer arn of whichever listener forwards
+// to it.
+const awsLbListenerRefType string = "load_balancer_arn"
+
+// AwsLbGroup is the synthetic Ansible group an aws_instance's associated
+// load balancer address (see Resource.AlternateAddresses) is exposed under.
+const AwsLbGroup string = "lb"
+
+// AWSStoreLb records an aws_lb/aws_alb's DNS name into carrierIndex, keyed
+// by its own arn, so a listener's target group can resolve through to it.
+func AWSStoreLb(attrs map[string]string) {
+	carrierIndex.storeAddress(attrs[awsLbArnKey], AwsLbGroup, attrs[awsLbDnsNameKey])
+}
+
+// AWSStoreLbListener records that a target group is forwarded to by a load
+// balancer, keyed by the target group's arn, so an instance attached to
+// that target group can resolve onward to the load balancer's address.
+func AWSStoreLbListener(attrs map[string]string) {
+	carrierIndex.storeRef(attrs[awsLbListenerTargetGroupArnKey], awsLbListenerRefType, attrs[awsLbListenerLbArnKey])
+}
+
+// AWSStoreLbTargetGroupAttachment records that an instance is attached to a
+// target group, keyed by the instance's id, so AlternateAddresses can
+// follow it through the target group's listener to a load balancer address.
+func AWSStoreLbTargetGroupAttachment(attrs map[string]string) {
+	carrierIndex.storeRef(attrs[awsTargetGroupAttachmentTargetIdKey], awsTargetGroupRefType, attrs[awsTargetGroupAttachmentArnKey])
+}
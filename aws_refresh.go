@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterRefreshProvider("aws_instance", awsInstanceRefreshProvider{})
+}
+
+const awsEc2APIVersion = "2016-11-15"
+
+// awsInstanceRefreshProvider re-fetches an EC2 instance's address and tags
+// straight from the EC2 API (DescribeInstances) instead of trusting the
+// statefile, so an instance's address reflects an EIP association or ENI
+// change made outside of the last apply. It authenticates the same way the
+// aws Terraform provider's static credentials do, via
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY(/AWS_SESSION_TOKEN) and
+// AWS_REGION/AWS_DEFAULT_REGION, and talks to the EC2 Query API directly
+// over net/http with a hand-rolled SigV4 signature rather than the AWS SDK
+// for Go, the same reasoning azureVMRefreshProvider gives for staying off
+// the Azure SDK.
+type awsInstanceRefreshProvider struct{}
+
+func (awsInstanceRefreshProvider) Refresh(r *Resource) (string, map[string]string, bool) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", nil, false
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return "", nil, false
+	}
+
+	instanceId := r.attr(resourceIdKey)
+	if instanceId == "" {
+		return "", nil, false
+	}
+
+	reservations, err := describeInstances(region, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), instanceId)
+	if err != nil || len(reservations) == 0 || len(reservations[0].Instances) == 0 {
+		return "", nil, false
+	}
+
+	inst := reservations[0].Instances[0]
+	address := inst.PublicIpAddress
+	if address == "" {
+		address = inst.PrivateIpAddress
+	}
+	if address == "" {
+		return "", nil, false
+	}
+
+	tags := make(map[string]string, len(inst.Tags))
+	for _, tag := range inst.Tags {
+		tags[tag.Key] = tag.Value
+	}
+
+	return address, tags, true
+}
+
+// ec2DescribeInstancesResponse is the subset of DescribeInstances' XML
+// response we need to resolve an instance's address and tags.
+type ec2DescribeInstancesResponse struct {
+	XMLName      xml.Name         `xml:"DescribeInstancesResponse"`
+	Reservations []ec2Reservation `xml:"reservationSet>item"`
+}
+
+type ec2Reservation struct {
+	Instances []ec2Instance `xml:"instancesSet>item"`
+}
+
+type ec2Instance struct {
+	PublicIpAddress  string   `xml:"ipAddress"`
+	PrivateIpAddress string   `xml:"privateIpAddress"`
+	Tags             []ec2Tag `xml:"tagSet>item"`
+}
+
+type ec2Tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+// describeInstances calls the EC2 Query API's DescribeInstances action for
+// a single instance id, returning the reservations it reports (normally
+// exactly one, containing exactly one instance).
+func describeInstances(region, accessKey, secretKey, sessionToken, instanceId string) ([]ec2Reservation, error) {
+	host := fmt.Sprintf("ec2.%s.amazonaws.com", region)
+	params := url.Values{
+		"Action":       {"DescribeInstances"},
+		"Version":      {awsEc2APIVersion},
+		"InstanceId.1": {instanceId},
+	}
+
+	req, err := signedEc2Request(host, region, accessKey, secretKey, sessionToken, params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ec2 DescribeInstances failed: %s: %s", resp.Status, body)
+	}
+
+	var out ec2DescribeInstancesResponse
+	if err := xml.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	return out.Reservations, nil
+}
+
+// signedEc2Request builds a GET request against the EC2 Query API, signed
+// with AWS Signature Version 4 the way every other EC2 client (including
+// the aws Terraform provider's underlying SDK) authenticates.
+func signedEc2Request(host, region, accessKey, secretKey, sessionToken string, params url.Values) (*http.Request, error) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	canonicalQuery := params.Encode()
+
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-date:%s\n", host, amzDate)
+	signedHeaders := "host;x-amz-date"
+	if sessionToken != "" {
+		canonicalHeaders += fmt.Sprintf("x-amz-security-token:%s\n", sessionToken)
+		signedHeaders += ";x-amz-security-token"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/",
+		canonicalQuery,
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(nil),
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/ec2/aws4_request", dateStamp, region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := awsSigningKey(secretKey, dateStamp, region, "ec2")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	authHeader := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, credentialScope, signedHeaders, signature)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/?%s", host, canonicalQuery), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Host", host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("Authorization", authHeader)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	return req, nil
+}
+
+// awsSigningKey derives a SigV4 request-signing key from a secret access
+// key, by way of date, region and service scoped HMAC-SHA256 hops.
+func awsSigningKey(secretKey, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, service)
+	return hmacSHA256(kService, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Azure related keys
+const azureNicResourceKey string = "azurerm_network_interface"
+const resourceIdKey string = "id"
+const azureVMPrimaryNicKey string = "primary_network_interface_id"
+const azureVMNicIdsPrefix string = "network_interface_ids."
+const azurePublicIpResourceKey string = "azurerm_public_ip"
+const azurePublicIpAddressKey string = "ip_address"
+
+// azureNicPublicIpRefType is the carrierIndex refType under which a NIC
+// records the azurerm_public_ip ids its ip_configuration blocks reference.
+const azureNicPublicIpRefType string = "public_ip_address_id"
+
+// privateScope and publicScope are the carrierIndex address scopes
+// an Azure NIC or public IP stores its addresses under.
+const privateScope string = "private"
+const publicScope string = "public"
+
+// azureVMTypes are the Terraform resource types that represent an Azure VM
+// and resolve their address through AzureAddress().
+var azureVMTypes = map[string]bool{
+	"azurerm_virtual_machine":         true,
+	"azurerm_linux_virtual_machine":   true,
+	"azurerm_windows_virtual_machine": true,
+}
+
+// azureIPTypeEnv selects whether AzureAddress() returns a private or public
+// address. Defaults to private for backwards compatibility with existing
+// inventories. There is no fallback: a VM with no address of the selected
+// scope has no address.
+const azureIPTypeEnv string = "TF_AZURE_IP_TYPE"
+const azureIPTypePublic string = "public"
+
+// azureIpConfigParser matches the attribute keys Terraform emits for each
+// ip_configuration block of an azurerm_network_interface, e.g.
+// "ip_configuration.0.private_ip_address" or
+// "ip_configuration.2.public_ip_address_id".
+var azureIpConfigParser = regexp.MustCompile(`^ip_configuration\.(\d+)\.(private_ip_address|public_ip_address_id)$`)
+
+func init() {
+	adapter := azureVMAdapter{}
+	for resourceType := range azureVMTypes {
+		RegisterProviderAdapter(resourceType, adapter)
+	}
+}
+
+// azureVMAdapter resolves azurerm_virtual_machine and
+// azurerm_linux_virtual_machine/azurerm_windows_virtual_machine addresses
+// and tags through carrierIndex, which NICs and public IPs feed as they're
+// parsed.
+type azureVMAdapter struct{}
+
+func (azureVMAdapter) AddressKeys() []string {
+	return nil
+}
+
+func (azureVMAdapter) ExtractTags(attrs map[string]string) map[string]string {
+	return tagContainer(attrs, "tags")
+}
+
+func (azureVMAdapter) ResolveAddress(r *Resource, index map[string]*Resource) string {
+	return r.AzureAddress()
+}
+
+// AzureAddress returns the address of an Azure VM by walking its primary and
+// secondary NICs (in the order Terraform lists them) and returning the first
+// matching address of the requested scope. The scope defaults to private and
+// can be switched to public via the TF_AZURE_IP_TYPE environment variable.
+func (r Resource) AzureAddress() string {
+	// We'll actually only handle the azurerm_*_virtual_machine family and
+	// ignore azurerm_network_interface as that is not a real VM resource
+	if !azureVMTypes[r.resourceType] {
+		return ""
+	}
+
+	scope := privateScope
+	if os.Getenv(azureIPTypeEnv) == azureIPTypePublic {
+		scope = publicScope
+	}
+
+	for _, nicId := range r.azureNicIds() {
+		if scope == publicScope {
+			if ip := carrierIndex.resolveVia(publicScope, azureNicPublicIpRefType, nicId); ip != "" {
+				return ip
+			}
+			continue
+		}
+
+		if ip := carrierIndex.resolve(privateScope, nicId); ip != "" {
+			return ip
+		}
+	}
+
+	return ""
+}
+
+// azureNicIds returns the ids of every NIC attached to this VM, primary NIC
+// first followed by network_interface_ids.0, .1, .2, ... in order.
+func (r Resource) azureNicIds() []string {
+	var ids []string
+
+	if nicId := r.attr(azureVMPrimaryNicKey); nicId != "" {
+		ids = append(ids, nicId)
+	}
+
+	for i := 0; ; i++ {
+		nicId := r.attr(fmt.Sprintf("%s%d", azureVMNicIdsPrefix, i))
+		if nicId == "" {
+			break
+		}
+		ids = append(ids, nicId)
+	}
+
+	return ids
+}
+
+// AzureStoreNic records every ip_configuration block (private IP and, if
+// present, a reference to an azurerm_public_ip) found on an
+// azurerm_network_interface, keyed by the NIC's own id, into carrierIndex.
+// attrs is the NIC's attribute map, flattened to dotted keys if it
+// originated from 0.12+ state.
+func AzureStoreNic(attrs map[string]string) {
+	nicId := attrs[resourceIdKey]
+	if nicId == "" {
+		return
+	}
+
+	configs := map[int]struct {
+		privateIp  string
+		publicIpId string
+	}{}
+
+	for k, v := range attrs {
+		m := azureIpConfigParser.FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+
+		c := configs[index]
+		switch m[2] {
+		case "private_ip_address":
+			c.privateIp = v
+		case "public_ip_address_id":
+			c.publicIpId = v
+		}
+		configs[index] = c
+	}
+
+	indices := make([]int, 0, len(configs))
+	for i := range configs {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	for _, i := range indices {
+		c := configs[i]
+		carrierIndex.storeAddress(nicId, privateScope, c.privateIp)
+		carrierIndex.storeRef(nicId, azureNicPublicIpRefType, c.publicIpId)
+	}
+}
+
+// AzureStorePublicIp records the address of an azurerm_public_ip resource,
+// keyed by its own id, into carrierIndex, so NIC ip_configuration blocks can
+// resolve their public_ip_address_id reference. attrs is the public IP's
+// attribute map, flattened to dotted keys if it originated from 0.12+ state.
+func AzureStorePublicIp(attrs map[string]string) {
+	carrierIndex.storeAddress(attrs[resourceIdKey], publicScope, attrs[azurePublicIpAddressKey])
+}
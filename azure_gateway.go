@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// GatewayIPGroup is the synthetic Ansible group a compute resource's
+// gateway_ip alternate address (see Resource.AlternateAddresses) is exposed
+// under.
+const GatewayIPGroup string = "gateway_ip"
+
+// azureBackendPoolAssociationTypes link a NIC to a backend address pool by
+// id. They carry no address of their own: azurerm_network_interface_*
+// glues the NIC to the pool, and it's the pool's gateway
+// (azurerm_application_gateway) that has a frontend address.
+var azureBackendPoolAssociationTypes = map[string]bool{
+	"azurerm_network_interface_backend_address_pool_association":                     true,
+	"azurerm_network_interface_application_gateway_backend_address_pool_association": true,
+}
+
+const azureBackendPoolAssocNicKey string = "network_interface_id"
+const azureBackendPoolAssocPoolRefType string = "backend_address_pool_id"
+
+const azureAppGatewayResourceKey string = "azurerm_application_gateway"
+
+// AzureStoreBackendPoolAssociation records that a NIC is attached to a
+// backend address pool, keyed by the NIC's id, so
+// Resource.AlternateAddresses can follow it to whatever gateway fronts that
+// pool.
+func AzureStoreBackendPoolAssociation(attrs map[string]string) {
+	carrierIndex.storeRef(attrs[azureBackendPoolAssocNicKey], azureBackendPoolAssocPoolRefType, attrs[azureBackendPoolAssocPoolRefType])
+}
+
+// AzureStoreAppGateway indexes an azurerm_application_gateway's frontend
+// address under the id of every backend pool it fronts, by walking its
+// backend_address_pool.N.id attributes. The frontend address is taken from
+// the gateway's first frontend_ip_configuration block, preferring its
+// public IP (resolved through carrierIndex, since that's populated from
+// azurerm_public_ip the same way a NIC's is) and falling back to its
+// private address.
+func AzureStoreAppGateway(attrs map[string]string) {
+	frontendIp := attrs["frontend_ip_configuration.0.private_ip_address"]
+	if publicIpId := attrs["frontend_ip_configuration.0.public_ip_address_id"]; publicIpId != "" {
+		if ip := carrierIndex.resolve(publicScope, publicIpId); ip != "" {
+			frontendIp = ip
+		}
+	}
+	if frontendIp == "" {
+		return
+	}
+
+	for i := 0; ; i++ {
+		poolId := attrs[fmt.Sprintf("backend_address_pool.%d.id", i)]
+		if poolId == "" {
+			break
+		}
+		carrierIndex.storeAddress(poolId, GatewayIPGroup, frontendIp)
+	}
+}
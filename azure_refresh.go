@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	provider := &azureVMRefreshProvider{}
+	for resourceType := range azureVMTypes {
+		RegisterRefreshProvider(resourceType, provider)
+	}
+}
+
+const azureTokenEndpoint = "https://login.microsoftonline.com/%s/oauth2/token"
+const azureManagementBaseURL = "https://management.azure.com"
+const azureNicAPIVersion = "2021-02-01"
+const azurePublicIPAPIVersion = "2021-02-01"
+
+// azureVMRefreshProvider re-fetches a VM's NIC address straight from the
+// Azure Resource Manager REST API instead of trusting the statefile, since a
+// NIC's ip_configuration commonly isn't populated in state until after a
+// second apply. It authenticates the same way the azurerm Terraform provider
+// does, via a service principal's ARM_CLIENT_ID/ARM_CLIENT_SECRET/
+// ARM_TENANT_ID, and talks to ARM directly over net/http rather than the
+// Azure SDK for Go so this tree doesn't need a go.mod to vendor it.
+type azureVMRefreshProvider struct {
+	mu    sync.Mutex
+	token string
+	exp   time.Time
+}
+
+func (p *azureVMRefreshProvider) Refresh(r *Resource) (string, map[string]string, bool) {
+	if os.Getenv("ARM_CLIENT_ID") == "" {
+		return "", nil, false
+	}
+
+	token, err := p.accessToken()
+	if err != nil {
+		return "", nil, false
+	}
+
+	scope := privateScope
+	if os.Getenv(azureIPTypeEnv) == azureIPTypePublic {
+		scope = publicScope
+	}
+
+	for _, nicId := range r.azureNicIds() {
+		var nic azureNicResponse
+		if err := azureGetJSON(token, azureManagementBaseURL+nicId+"?api-version="+azureNicAPIVersion, &nic); err != nil {
+			continue
+		}
+
+		if ip, err := nic.address(token, scope); err == nil && ip != "" {
+			return ip, nil, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// accessToken returns a cached OAuth2 bearer token for the Azure Resource
+// Manager API, requesting a fresh one via the client credentials grant once
+// the cached token is within a minute of expiring.
+func (p *azureVMRefreshProvider) accessToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Now().Before(p.exp) {
+		return p.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {os.Getenv("ARM_CLIENT_ID")},
+		"client_secret": {os.Getenv("ARM_CLIENT_SECRET")},
+		"resource":      {azureManagementBaseURL + "/"},
+	}
+
+	resp, err := http.PostForm(fmt.Sprintf(azureTokenEndpoint, os.Getenv("ARM_TENANT_ID")), form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("azure token request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	ttl, err := strconv.Atoi(body.ExpiresIn)
+	if err != nil {
+		ttl = 3600
+	}
+
+	p.token = body.AccessToken
+	p.exp = time.Now().Add(time.Duration(ttl-60) * time.Second)
+	return p.token, nil
+}
+
+// azureNicResponse is the subset of a network interface's ARM REST
+// representation we need to resolve an address.
+type azureNicResponse struct {
+	Properties struct {
+		IPConfigurations []struct {
+			Properties struct {
+				PrivateIPAddress string `json:"privateIPAddress"`
+				PublicIPAddress  *struct {
+					ID         string `json:"id"`
+					Properties struct {
+						IPAddress string `json:"ipAddress"`
+					} `json:"properties"`
+				} `json:"publicIPAddress"`
+			} `json:"properties"`
+		} `json:"ipConfigurations"`
+	} `json:"properties"`
+}
+
+// address returns the first address of scope found across this NIC's
+// ip_configuration blocks. A public address whose id wasn't inlined by ARM
+// (no $expand was requested) is resolved with a second GET of the public IP
+// resource itself, the same reference azurerm_public_ip's id is.
+func (nic azureNicResponse) address(token, scope string) (string, error) {
+	for _, cfg := range nic.Properties.IPConfigurations {
+		if scope == privateScope {
+			if cfg.Properties.PrivateIPAddress != "" {
+				return cfg.Properties.PrivateIPAddress, nil
+			}
+			continue
+		}
+
+		pip := cfg.Properties.PublicIPAddress
+		if pip == nil {
+			continue
+		}
+		if pip.Properties.IPAddress != "" {
+			return pip.Properties.IPAddress, nil
+		}
+
+		var resp struct {
+			Properties struct {
+				IPAddress string `json:"ipAddress"`
+			} `json:"properties"`
+		}
+		if err := azureGetJSON(token, azureManagementBaseURL+pip.ID+"?api-version="+azurePublicIPAPIVersion, &resp); err != nil {
+			return "", err
+		}
+		if resp.Properties.IPAddress != "" {
+			return resp.Properties.IPAddress, nil
+		}
+	}
+
+	return "", nil
+}
+
+// azureGetJSON issues an authenticated GET against the Azure Resource
+// Manager API and decodes its JSON body into out.
+func azureGetJSON(token, requestURL string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure request to %s failed: %s", requestURL, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestAzureStoreNicAndAddress(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	AzureStorePublicIp(map[string]string{
+		resourceIdKey:           "/subscriptions/1/publicIPAddresses/pip1",
+		azurePublicIpAddressKey: "2.2.2.2",
+	})
+
+	AzureStoreNic(map[string]string{
+		resourceIdKey:                             "/subscriptions/1/networkInterfaces/nic1",
+		"ip_configuration.0.private_ip_address":   "10.0.0.4",
+		"ip_configuration.0.public_ip_address_id": "/subscriptions/1/publicIPAddresses/pip1",
+		"ip_configuration.1.private_ip_address":   "10.0.0.5",
+	})
+
+	if got := carrierIndex.resolve(privateScope, "/subscriptions/1/networkInterfaces/nic1"); got != "10.0.0.4" {
+		t.Errorf("private resolve = %q, want %q (first ip_configuration block)", got, "10.0.0.4")
+	}
+
+	if got := carrierIndex.resolveVia(publicScope, azureNicPublicIpRefType, "/subscriptions/1/networkInterfaces/nic1"); got != "2.2.2.2" {
+		t.Errorf("public resolveVia = %q, want %q", got, "2.2.2.2")
+	}
+}
+
+func TestAzureStoreNicIgnoresUnrelatedKeys(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	AzureStoreNic(map[string]string{
+		resourceIdKey:        "/subscriptions/1/networkInterfaces/nic2",
+		"name":               "nic2",
+		"location":           "westeurope",
+		"ip_configuration.#": "1",
+	})
+
+	if got := carrierIndex.resolve(privateScope, "/subscriptions/1/networkInterfaces/nic2"); got != "" {
+		t.Errorf("resolve = %q, want \"\" (no ip_configuration blocks present)", got)
+	}
+}
+
+func TestAzureStoreNicSparseIndices(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	AzureStoreNic(map[string]string{
+		resourceIdKey:                           "/subscriptions/1/networkInterfaces/nic3",
+		"ip_configuration.0.private_ip_address": "10.0.0.4",
+		"ip_configuration.2.private_ip_address": "10.0.0.6",
+	})
+
+	if got := carrierIndex.resolve(privateScope, "/subscriptions/1/networkInterfaces/nic3"); got != "10.0.0.4" {
+		t.Errorf("private resolve = %q, want %q (lowest index wins, even with a gap)", got, "10.0.0.4")
+	}
+
+	c := carrierIndex.carriers["/subscriptions/1/networkInterfaces/nic3"]
+	if len(c.addresses[privateScope]) != 2 {
+		t.Errorf("expected both .0 and .2 addresses to be stored despite the missing .1, got %v", c.addresses[privateScope])
+	}
+}
+
+func TestAzureStoreNicNoId(t *testing.T) {
+	carrierIndex = newAssociationIndex()
+
+	AzureStoreNic(map[string]string{
+		"ip_configuration.0.private_ip_address": "10.0.0.4",
+	})
+
+	if len(carrierIndex.carriers) != 0 {
+		t.Errorf("expected no carrier to be recorded without a NIC id, got %d", len(carrierIndex.carriers))
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import "fmt"
+
+// GCE load balancer related keys. A google_compute_instance_group carries no
+// address of its own - it's whichever google_compute_backend_service fronts
+// it (by way of a backend.N.group reference), and that backend service's
+// google_compute_forwarding_rule, that carries the actual ip_address. Only
+// the regional, backend_service-fronted forwarding rule shape is handled
+// here; a global forwarding rule reaches its backend service through a
+// target proxy and url map instead, which is its own chain.
+const gceInstanceGroupResourceKey string = "google_compute_instance_group"
+const gceBackendServiceResourceKey string = "google_compute_backend_service"
+const gceForwardingRuleResourceKey string = "google_compute_forwarding_rule"
+const gceForwardingRuleBackendServiceKey string = "backend_service"
+const gceForwardingRuleIpAddressKey string = "ip_address"
+
+// gceInstanceGroupRefType is the carrierIndex refType under which an
+// instance's self_link records the instance group(s) it's a member of.
+const gceInstanceGroupRefType string = "instance_group"
+
+// gceBackendServiceRefType is the carrierIndex refType under which an
+// instance group's self_link records the backend service(s) that front it.
+const gceBackendServiceRefType string = "backend_service"
+
+// GCEStoreInstanceGroup records that every instance self_link listed in a
+// google_compute_instance_group's instances set is a member of that group,
+// keyed by the instance's own self_link, so AlternateAddresses can follow it
+// onward through whichever backend service fronts the group. The group
+// itself is indexed by its self_link, not its id, since that's the form a
+// google_compute_backend_service's backend.N.group attribute references it
+// by - GCE cross-resource references are always self_links.
+func GCEStoreInstanceGroup(attrs map[string]string) {
+	groupId := attrs["self_link"]
+	if groupId == "" {
+		return
+	}
+
+	for i := 0; ; i++ {
+		instanceId := attrs[fmt.Sprintf("instances.%d", i)]
+		if instanceId == "" {
+			break
+		}
+		carrierIndex.storeRef(instanceId, gceInstanceGroupRefType, groupId)
+	}
+}
+
+// GCEStoreBackendService records that a backend service fronts every
+// instance group its backend.N.group attributes reference, keyed by the
+// group's self_link, so an instance belonging to that group can resolve
+// onward to whichever forwarding rule targets the backend service. The
+// backend service itself is indexed by its self_link, not its id, to match
+// how a google_compute_forwarding_rule's backend_service attribute
+// references it.
+func GCEStoreBackendService(attrs map[string]string) {
+	backendServiceId := attrs["self_link"]
+	if backendServiceId == "" {
+		return
+	}
+
+	for i := 0; ; i++ {
+		groupId := attrs[fmt.Sprintf("backend.%d.group", i)]
+		if groupId == "" {
+			break
+		}
+		carrierIndex.storeRef(groupId, gceBackendServiceRefType, backendServiceId)
+	}
+}
+
+// GCEStoreForwardingRule records a regional forwarding rule's ip_address
+// into carrierIndex, keyed by the backend service it targets, so an instance
+// group member can resolve through to it.
+func GCEStoreForwardingRule(attrs map[string]string) {
+	carrierIndex.storeAddress(attrs[gceForwardingRuleBackendServiceKey], GatewayIPGroup, attrs[gceForwardingRuleIpAddressKey])
+}
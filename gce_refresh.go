@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterRefreshProvider("google_compute_instance", &gceInstanceRefreshProvider{})
+}
+
+// gceComputeScope is the OAuth2 scope requested for Compute Engine reads.
+const gceComputeScope string = "https://www.googleapis.com/auth/compute.readonly"
+
+// gceInstanceRefreshProvider re-fetches a VM's address and labels straight
+// from the Compute Engine API (instances.get) instead of trusting the
+// statefile, since an ephemeral external NAT IP can be reassigned out from
+// under a stale state. It authenticates the same way the google Terraform
+// provider's GOOGLE_APPLICATION_CREDENTIALS service account key does - a
+// self-signed RS256 JWT exchanged for an OAuth2 bearer token - and talks to
+// the Compute API directly over net/http rather than the Google Cloud SDK
+// for Go, the same reasoning azureVMRefreshProvider gives for staying off
+// the Azure SDK.
+type gceInstanceRefreshProvider struct {
+	mu    sync.Mutex
+	token string
+	exp   time.Time
+}
+
+func (p *gceInstanceRefreshProvider) Refresh(r *Resource) (string, map[string]string, bool) {
+	keyPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if keyPath == "" {
+		return "", nil, false
+	}
+
+	selfLink := r.attr("self_link")
+	if selfLink == "" {
+		return "", nil, false
+	}
+
+	token, err := p.accessToken(keyPath)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var inst gceInstanceResponse
+	if err := gceGetJSON(token, selfLink, &inst); err != nil {
+		return "", nil, false
+	}
+
+	address := inst.address()
+	if address == "" {
+		return "", nil, false
+	}
+
+	return address, inst.Labels, true
+}
+
+// accessToken returns a cached OAuth2 bearer token for the Compute Engine
+// API, minting a fresh one via the JWT bearer grant once the cached token is
+// within a minute of expiring.
+func (p *gceInstanceRefreshProvider) accessToken(keyPath string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Now().Before(p.exp) {
+		return p.token, nil
+	}
+
+	key, err := loadGCEServiceAccountKey(keyPath)
+	if err != nil {
+		return "", err
+	}
+
+	assertion, err := key.signedJWT(gceComputeScope)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.PostForm(key.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gce token request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	p.token = body.AccessToken
+	p.exp = time.Now().Add(time.Duration(body.ExpiresIn-60) * time.Second)
+	return p.token, nil
+}
+
+// gceServiceAccountKey is the subset of a GOOGLE_APPLICATION_CREDENTIALS
+// service account key file needed to mint a signed JWT.
+type gceServiceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	parsedKey *rsa.PrivateKey
+}
+
+// loadGCEServiceAccountKey reads and parses a service account key file, the
+// same JSON format GOOGLE_APPLICATION_CREDENTIALS points the google
+// Terraform provider at.
+func loadGCEServiceAccountKey(path string) (*gceServiceAccountKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key gceServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in private_key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key is not an RSA key")
+	}
+	key.parsedKey = rsaKey
+
+	return &key, nil
+}
+
+// signedJWT builds and RS256-signs a self-issued JWT requesting scope, good
+// for one hour, the same grant the google Terraform provider's service
+// account auth uses.
+func (k *gceServiceAccountKey) signedJWT(scope string) (string, error) {
+	now := time.Now().UTC()
+
+	header := base64URLEncode([]byte(`{"alg":"RS256","typ":"JWT"}`))
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   k.ClientEmail,
+		"scope": scope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	payload := base64URLEncode(claims)
+
+	signingInput := header + "." + payload
+	hashed := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.parsedKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64URLEncode(signature), nil
+}
+
+func base64URLEncode(data []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(data), "=")
+}
+
+// gceInstanceResponse is the subset of an instance's Compute Engine REST
+// representation we need to resolve an address and labels.
+type gceInstanceResponse struct {
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+// address returns the first public (NAT) address found across this
+// instance's network interfaces, falling back to the first private address
+// if none has one. googleComputeInstanceAdapter instead checks the
+// statefile-derived attributes one network interface at a time, so there an
+// earlier interface's private address wins over a later interface's NAT
+// address.
+func (inst gceInstanceResponse) address() string {
+	for _, nic := range inst.NetworkInterfaces {
+		for _, ac := range nic.AccessConfigs {
+			if ac.NatIP != "" {
+				return ac.NatIP
+			}
+		}
+	}
+
+	for _, nic := range inst.NetworkInterfaces {
+		if nic.NetworkIP != "" {
+			return nic.NetworkIP
+		}
+	}
+
+	return ""
+}
+
+// gceGetJSON issues an authenticated GET against the Compute Engine API and
+// decodes its JSON body into out.
+func gceGetJSON(token, requestURL string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gce request to %s failed: %s", requestURL, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
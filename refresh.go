@@ -0,0 +1,65 @@
+package main
+
+import "os"
+
+// RefreshEnv, when set to any non-empty value, turns on live-infrastructure
+// refresh (see RefreshEnabled, which also honours a flag value passed in by
+// its caller): for resource types with a RefreshProvider registered, their
+// statefile-derived address/tags are overridden with whatever the
+// provider's API reports right now. This matters most for resources where
+// the statefile is commonly stale or incomplete - Azure NIC IPs often don't
+// appear until after a second apply, and GCE ephemeral NAT IPs rotate out
+// from under a stale state.
+const RefreshEnv string = "TF_INV_REFRESH"
+
+// RefreshEnabled reports whether live refresh was requested, either via the
+// flag argument its caller passes or the TF_INV_REFRESH environment variable.
+func RefreshEnabled(flag bool) bool {
+	return flag || os.Getenv(RefreshEnv) != ""
+}
+
+// RefreshProvider fetches a resource's current address and tags directly
+// from its cloud API, for providers/resource types where the statefile
+// alone isn't trustworthy. Register an implementation from init() in its
+// own file, the same way ProviderAdapter implementations are (see
+// azure_refresh.go, gce_refresh.go, aws_refresh.go). Authentication should
+// reuse the same environment variables the corresponding Terraform provider
+// itself reads, so a working `terraform apply` implies a working refresh.
+type RefreshProvider interface {
+	// Refresh returns the live address and tags for r. ok is false if the
+	// resource couldn't be refreshed (no id, API error, no client
+	// configured), in which case the statefile-derived values are kept.
+	Refresh(r *Resource) (address string, tags map[string]string, ok bool)
+}
+
+var refreshProviders = map[string]RefreshProvider{}
+
+// RegisterRefreshProvider makes a RefreshProvider available for the given
+// Terraform resource type. Panics on a duplicate registration, since that
+// can only be a programming mistake.
+func RegisterRefreshProvider(resourceType string, provider RefreshProvider) {
+	if _, exists := refreshProviders[resourceType]; exists {
+		panic("refresh provider already registered for " + resourceType)
+	}
+	refreshProviders[resourceType] = provider
+}
+
+// Refresh merges live data over this resource's statefile-derived address
+// and tags, if a RefreshProvider is registered for its type and enabled is
+// true. It's a no-op otherwise, so calling it unconditionally from main's
+// resource-loading loop (after RefreshEnabled) is always safe.
+func (r *Resource) Refresh(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	provider, ok := refreshProviders[r.resourceType]
+	if !ok {
+		return
+	}
+
+	if address, tags, ok := provider.Refresh(r); ok {
+		r.refreshedAddress = &address
+		r.refreshedTags = tags
+	}
+}
@@ -5,51 +5,11 @@ import (
 	"os"
 	"regexp"
 	"strconv"
-	"strings"
 )
 
-// keyNames contains the names of the keys to check for in each resource in the
-// state file. This allows us to support multiple types of resource without too
-// much fuss.
-var keyNames []string
 var nameParser *regexp.Regexp
 
-// Azure has separate resources for the VM and the NIC that holds the IP address
-// Everytime we encounter an azurerm_network_interface we will store the IP address
-// in this map with the NIC id as the key. Then when we are looking for the VM address
-// we'll check if the VM's (primary) NIC exists in the map.
-var azureNICPrimaryIps map[string]string
-
-// Azure related keys
-const azureNicResourceKey string = "azurerm_network_interface"
-const azureNicIpKey string = "private_ip_address"
-const azureIdKey string = "id"
-const azureVMResourceKey string = "azurerm_virtual_machine"
-const azureVMPrimaryNicKey string = "primary_network_interface_id"
-const azureVMSecondaryNicKey string = "network_interface_ids.0"
-
 func init() {
-	keyNames = []string{
-		"ipv4_address",                                        // DO and SoftLayer
-		"public_ip",                                           // AWS
-		"public_ipv6",                                         // Scaleway
-		"private_ip",                                          // AWS
-		"ipaddress",                                           // CS
-		"ip_address",                                          // VMware, Docker
-		"network_interface.0.ipv4_address",                    // VMware
-		"default_ip_address",                                  // provider.vsphere v1.1.1
-		"access_ip_v4",                                        // OpenStack
-		"floating_ip",                                         // OpenStack
-		"network_interface.0.access_config.0.nat_ip",          // GCE
-		"network_interface.0.access_config.0.assigned_nat_ip", // GCE
-		"network_interface.0.address",                         // GCE
-		"ipv4_address_private",                                // SoftLayer
-		"networks.0.ip4address",                               // Exoscale
-		"primaryip",                                           // Joyent Triton
-	}
-
-	azureNICPrimaryIps = map[string]string{}
-
 	// type.name.0
 	nameParser = regexp.MustCompile(`^(\w+)\.([\w\-]+)(?:\.(\d+))?$`)
 }
@@ -67,7 +27,23 @@ type Resource struct {
 	// Extracted from keyName
 	resourceType string
 	baseName     string
-	counter      int
+
+	// counterKey is the raw index of this resource among others sharing the
+	// same baseName. For the legacy flatmap format it's always a decimal
+	// string ("0", "1", ...). For 0.12+ state it's either a decimal string
+	// (from count) or an arbitrary for_each key (e.g. "frontend-a").
+	counterKey string
+
+	// v2Attrs holds the structured attributes of a resource parsed from a
+	// 0.12+ statefile. It is nil for resources parsed from legacy flatmap
+	// state, in which case State.Primary.Attributes is used instead.
+	v2Attrs map[string]interface{}
+
+	// refreshedAddress and refreshedTags override the statefile-derived
+	// Address()/Tags() once Refresh() has found live data for this
+	// resource via a registered RefreshProvider. Both are nil until then.
+	refreshedAddress *string
+	refreshedTags    map[string]string
 }
 
 func NewResource(keyName string, state resourceState) (*Resource, error) {
@@ -79,30 +55,31 @@ func NewResource(keyName string, state resourceState) (*Resource, error) {
 		return nil, fmt.Errorf("couldn't parse keyName: %s", keyName)
 	}
 
-	var c int
-	var err error
+	counterKey := "0"
 	if m[3] != "" {
 
 		// The third section should be the index, if it's present. Not sure what
 		// else we can do other than panic (which seems highly undesirable) if that
 		// isn't the case.
-		c, err = strconv.Atoi(m[3])
-		if err != nil {
+		if _, err := strconv.Atoi(m[3]); err != nil {
 			return nil, err
 		}
+		counterKey = m[3]
 	}
 
-	// Special case for azurerm_network_interface
-	if m[1] == azureNicResourceKey {
-		AzureStoreNicIp(state)
-	}
+	// Special cases for carrier/association resources that feed
+	// carrierIndex rather than being addressed directly themselves: NICs
+	// and public IPs feed AzureAddress(), backend pool associations and
+	// gateways feed AlternateAddresses()'s gateway_ip group, and EIPs/EIP
+	// associations feed its eip group.
+	storeCarrier(m[1], state.Primary.Attributes)
 
 	return &Resource{
 		State:        state,
 		keyName:      keyName,
 		resourceType: m[1],
 		baseName:     m[2],
-		counter:      c,
+		counterKey:   counterKey,
 	}, nil
 }
 
@@ -112,132 +89,127 @@ func (r Resource) IsSupported() bool {
 
 // Tags returns a map of arbitrary key/value pairs explicitly associated with
 // the resource. Different providers have different mechanisms for attaching
-// these.
+// these, so this is delegated to the ProviderAdapter registered for the
+// resource's Terraform type; resource types with no registered adapter have
+// no tags.
 func (r Resource) Tags() map[string]string {
-	t := map[string]string{}
-
-	switch r.resourceType {
-	case "openstack_compute_instance_v2":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			// At some point Terraform changed the key for counts of attributes to end with ".%"
-			// instead of ".#". Both need to be considered as Terraform still supports state
-			// files using the old format.
-			if len(parts) == 2 && parts[0] == "metadata" && parts[1] != "#" && parts[1] != "%" {
-				kk := strings.ToLower(parts[1])
-				vv := strings.ToLower(v)
-				t[kk] = vv
-			}
-		}
-	case "aws_instance":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			// At some point Terraform changed the key for counts of attributes to end with ".%"
-			// instead of ".#". Both need to be considered as Terraform still supports state
-			// files using the old format.
-			if len(parts) == 2 && parts[0] == "tags" && parts[1] != "#" && parts[1] != "%" {
-				kk := strings.ToLower(parts[1])
-				vv := strings.ToLower(v)
-				t[kk] = vv
-			}
-		}
-	case "vsphere_virtual_machine":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			if len(parts) == 2 && parts[0] == "custom_configuration_parameters" && parts[1] != "#" && parts[1] != "%" {
-				kk := strings.ToLower(parts[1])
-				vv := strings.ToLower(v)
-				t[kk] = vv
-			}
-		}
-	case "digitalocean_droplet", "google_compute_instance", "scaleway_server":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			if len(parts) == 2 && parts[0] == "tags" && parts[1] != "#" {
-				vv := strings.ToLower(v)
-				t[vv] = ""
-			}
-		}
-	case "triton_machine":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			if len(parts) == 2 && parts[0] == "tags" && parts[1] != "%" {
-				kk := strings.ToLower(parts[1])
-				vv := strings.ToLower(v)
-				t[kk] = vv
-			}
-		}
-	case "azurerm_virtual_machine":
-		for k, v := range r.Attributes() {
-			parts := strings.SplitN(k, ".", 2)
-			if len(parts) == 2 && parts[0] == "tags" && parts[1] != "%" {
-				kk := strings.ToLower(parts[1])
-				vv := strings.ToLower(v)
-				t[kk] = vv
-			}
-		}
+	if r.refreshedTags != nil {
+		return r.refreshedTags
+	}
+
+	adapter, ok := providerAdapters[r.resourceType]
+	if !ok {
+		return map[string]string{}
 	}
-	return t
+	return adapter.ExtractTags(r.Attributes())
 }
 
-// Attributes returns a map containing everything we know about this resource.
+// Attributes returns a flat, dotted-key map of everything we know about this
+// resource (e.g. "network_interface.0.access_config.0.nat_ip"), regardless
+// of whether it came from legacy flatmap state or 0.12+ structured state.
 func (r Resource) Attributes() map[string]string {
+	if r.v2Attrs != nil {
+		return flattenAttributes(r.v2Attrs)
+	}
 	return r.State.Primary.Attributes
 }
 
+// attr looks up a single attribute by its dotted path (e.g.
+// "network_interface.0.access_config.0.nat_ip"), regardless of whether this
+// resource came from legacy flatmap state or 0.12+ structured state. Prefer
+// this over Attributes() for single-key lookups against a 0.12+ resource,
+// since it walks the structured attributes directly instead of flattening
+// all of them first.
+func (r Resource) attr(path string) string {
+	if r.v2Attrs != nil {
+		return lookupNestedString(r.v2Attrs, path)
+	}
+	return r.State.Primary.Attributes[path]
+}
+
 // NameWithCounter returns the resource name with its counter. For resources
-// created without a 'count=' attribute, this will always be zero.
+// created without a 'count=' attribute, this will always be zero. Resources
+// created with for_each instead carry their string key here (e.g.
+// "frontend-a") rather than a numeric index.
 func (r Resource) NameWithCounter() string {
-	return fmt.Sprintf("%s.%d", r.baseName, r.counter)
+	return fmt.Sprintf("%s.%s", r.baseName, r.counterKey)
 }
 
-// Address returns the IP address of this resource.
+// Address returns the IP address of this resource, as resolved by the
+// ProviderAdapter registered for the resource's Terraform type. Resource
+// types with no registered adapter have no address, unless TF_KEY_NAME
+// overrides that - it takes precedence over any adapter and works even for
+// types with no adapter registered at all, so a single attribute name can
+// address an otherwise-unsupported resource type.
 func (r Resource) Address() string {
-
-	switch r.resourceType {
-	case azureNicResourceKey, azureVMResourceKey:
-		// Special case for azurerm_network_interface, azurerm_virtual_machine
-		return r.AzureAddress()
+	if r.refreshedAddress != nil {
+		return *r.refreshedAddress
 	}
 
 	if keyName := os.Getenv("TF_KEY_NAME"); keyName != "" {
-		if ip := r.State.Primary.Attributes[keyName]; ip != "" {
+		return r.attr(keyName)
+	}
+
+	adapter, ok := providerAdapters[r.resourceType]
+	if !ok {
+		return ""
+	}
+
+	// Adapters that need to follow a reference to another resource (an
+	// Azure NIC, a GCE network_interface) get first refusal; everything
+	// else falls through to AddressKeys.
+	if addr := adapter.ResolveAddress(&r, nil); addr != "" {
+		return addr
+	}
+
+	for _, key := range adapter.AddressKeys() {
+		if ip := r.attr(key); ip != "" {
 			return ip
 		}
-	} else {
-		for _, key := range keyNames {
-			if ip := r.State.Primary.Attributes[key]; ip != "" {
-				return ip
-			}
-		}
 	}
 
 	return ""
 }
 
-func (r Resource) AzureAddress() string {
-	// We'll actually only handle azurerm_virtual_machine and ignore
-	// azurerm_network_interface as that is not a real VM resource
-	if r.resourceType == azureVMResourceKey {
-		nicId := r.State.Primary.Attributes[azureVMPrimaryNicKey]
-		if nicId == "" {
-			nicId = r.State.Primary.Attributes[azureVMSecondaryNicKey]
-		}
-		if nicId != "" {
-			ip := azureNICPrimaryIps[nicId]
-			return ip
+// AlternateAddresses returns additional addresses this resource can be
+// reached at beyond its primary Address(), keyed by the synthetic group
+// they should be reported under. This is pass 2 of the two-pass resolver:
+// by the time it's called every resource's carrier/association data has
+// already been indexed into carrierIndex during parsing (pass 1), so a
+// compute resource can be reported, e.g., under both its own address and
+// its application gateway's frontend IP under GatewayIPGroup.
+func (r Resource) AlternateAddresses() map[string]string {
+	alt := map[string]string{}
+
+	if azureVMTypes[r.resourceType] {
+		if ip := carrierIndex.resolveVia(GatewayIPGroup, azureBackendPoolAssocPoolRefType, r.azureNicIds()...); ip != "" {
+			alt[GatewayIPGroup] = ip
 		}
 	}
 
-	return ""
-}
+	if r.resourceType == "aws_instance" {
+		instanceId := r.attr(resourceIdKey)
 
-func AzureStoreNicIp(state resourceState) {
-	// Store the first ipAddress (primary) to the map with nic id
-	ip := state.Primary.Attributes[azureNicIpKey]
-	nicId := state.Primary.Attributes[azureIdKey]
+		if ip := carrierIndex.resolveVia(publicScope, awsEipRefType, instanceId); ip != "" {
+			alt[AwsEipGroup] = ip
+		}
 
-	if ip != "" && nicId != "" {
-		azureNICPrimaryIps[nicId] = ip
+		if tgArn := carrierIndex.firstRef(instanceId, awsTargetGroupRefType); tgArn != "" {
+			if ip := carrierIndex.resolveVia(AwsLbGroup, awsLbListenerRefType, tgArn); ip != "" {
+				alt[AwsLbGroup] = ip
+			}
+		}
 	}
+
+	if r.resourceType == "google_compute_instance" {
+		selfLink := r.attr("self_link")
+
+		if groupId := carrierIndex.firstRef(selfLink, gceInstanceGroupRefType); groupId != "" {
+			if ip := carrierIndex.resolveVia(GatewayIPGroup, gceBackendServiceRefType, groupId); ip != "" {
+				alt[GatewayIPGroup] = ip
+			}
+		}
+	}
+
+	return alt
 }
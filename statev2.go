@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// legacyStateVersion is the last top-level statefile "version" that used the
+// flatmap "modules[].resources" layout parsed by NewResource. From version 4
+// onwards (Terraform 0.12+) state uses the "resources[].instances" layout
+// parsed by ParseStateV2 instead.
+const legacyStateVersion = 3
+
+// stateVersionProbe is unmarshalled first so we can decide which parser a
+// statefile needs without committing to either format's full struct.
+type stateVersionProbe struct {
+	Version int `json:"version"`
+}
+
+// IsLegacyState reports whether a statefile's top-level "version" field
+// indicates the pre-0.12 flatmap layout (parsed via NewResource) rather than
+// the 0.12+ instances layout (parsed via ParseStateV2).
+func IsLegacyState(data []byte) (bool, error) {
+	var probe stateVersionProbe
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false, err
+	}
+	return probe.Version <= legacyStateVersion, nil
+}
+
+// LoadState parses a Terraform statefile, autodetecting its layout from the
+// top-level "version" field: pre-0.12 flatmap state goes through
+// parseLegacyState/NewResource, 0.12+ state goes through ParseStateV2. This
+// is the single entry point that should be called with raw statefile bytes
+// rather than picking a parser directly.
+func LoadState(data []byte) ([]*Resource, error) {
+	legacy, err := IsLegacyState(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if legacy {
+		return parseLegacyState(data)
+	}
+	return ParseStateV2(data)
+}
+
+// legacyState is the top-level "modules[].resources" shape of a pre-0.12
+// statefile. Each resource entry unmarshals directly into resourceState,
+// the same type NewResource already expects.
+type legacyState struct {
+	Modules []struct {
+		Resources map[string]resourceState `json:"resources"`
+	} `json:"modules"`
+}
+
+// parseLegacyState parses a pre-0.12 flatmap statefile into one Resource per
+// resource key, in the same "type.name.N" form NewResource parses.
+func parseLegacyState(data []byte) ([]*Resource, error) {
+	var s legacyState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, m := range s.Modules {
+		for keyName, state := range m.Resources {
+			r, err := NewResource(keyName, state)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, r)
+		}
+	}
+
+	return resources, nil
+}
+
+// stateV2 is the top-level shape of a Terraform 0.12+ (state format 4)
+// statefile.
+type stateV2 struct {
+	Version   int               `json:"version"`
+	Resources []resourceStateV2 `json:"resources"`
+}
+
+// resourceStateV2 is a single resource block in a 0.12+ statefile. Unlike
+// the legacy flatmap layout, every count/for_each instance of the resource
+// is nested under Instances rather than being its own top-level key.
+type resourceStateV2 struct {
+	Mode      string               `json:"mode"`
+	Type      string               `json:"type"`
+	Name      string               `json:"name"`
+	Instances []resourceInstanceV2 `json:"instances"`
+}
+
+// resourceInstanceV2 is one instance (one count index or for_each key) of a
+// resourceStateV2. Attributes are structured JSON rather than the dotted
+// flatmap strings used by the legacy format.
+type resourceInstanceV2 struct {
+	IndexKey   interface{}            `json:"index_key"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ParseStateV2 parses a Terraform 0.12+ (state format 4) statefile into one
+// Resource per resource instance. Data resources are skipped, since they
+// don't represent infrastructure with an address of their own.
+func ParseStateV2(data []byte) ([]*Resource, error) {
+	var s stateV2
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, rs := range s.Resources {
+		if rs.Mode != "managed" {
+			continue
+		}
+
+		for _, inst := range rs.Instances {
+			r, err := NewResourceV2(rs.Type, rs.Name, inst)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, r)
+		}
+	}
+
+	return resources, nil
+}
+
+// NewResourceV2 builds a Resource from a single 0.12+ resource instance. The
+// index key may be an integer (from count), a string (from for_each), or
+// absent (no count/for_each); all three are preserved in counterKey so
+// NameWithCounter can render e.g. "web.0" or "web.frontend-a".
+func NewResourceV2(resourceType, name string, inst resourceInstanceV2) (*Resource, error) {
+	counterKey := "0"
+	switch k := inst.IndexKey.(type) {
+	case nil:
+		// no count/for_each: Terraform still reports a single instance
+	case float64:
+		// encoding/json decodes all JSON numbers as float64
+		counterKey = strconv.Itoa(int(k))
+	case string:
+		counterKey = k
+	default:
+		return nil, fmt.Errorf("unsupported index_key type for %s.%s: %T", resourceType, name, k)
+	}
+
+	// Same carrier/association special case as NewResource. storeCarrier's
+	// Store* functions all expect dotted flatmap keys, so flatten the
+	// structured attributes first.
+	storeCarrier(resourceType, flattenAttributes(inst.Attributes))
+
+	return &Resource{
+		keyName:      fmt.Sprintf("%s.%s.%s", resourceType, name, counterKey),
+		resourceType: resourceType,
+		baseName:     name,
+		counterKey:   counterKey,
+		v2Attrs:      inst.Attributes,
+	}, nil
+}
+
+// lookupNestedString walks a dotted attribute path (the same paths used in
+// each ProviderAdapter's AddressKeys, e.g.
+// "network_interface.0.access_config.0.nat_ip") through the structured
+// attributes of a 0.12+ resource instance, indexing into a list
+// when a path segment is numeric and into a map otherwise. It returns "" if
+// the path doesn't exist or doesn't resolve to a string.
+func lookupNestedString(attrs map[string]interface{}, path string) string {
+	var cur interface{} = attrs
+	for _, part := range strings.Split(path, ".") {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[part]
+			if !ok {
+				return ""
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(c) {
+				return ""
+			}
+			cur = c[i]
+		default:
+			return ""
+		}
+	}
+
+	s, ok := cur.(string)
+	if !ok {
+		return ""
+	}
+	return s
+}
+
+// flattenAttributes converts the structured attributes of a 0.12+ resource
+// instance into the dotted flatmap form the legacy Azure NIC/public IP
+// helpers expect (e.g. "ip_configuration.0.private_ip_address").
+func flattenAttributes(attrs map[string]interface{}) map[string]string {
+	flat := map[string]string{}
+	flattenValue(attrs, "", flat)
+	return flat
+}
+
+func flattenValue(v interface{}, prefix string, out map[string]string) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, child := range vv {
+			flattenValue(child, prefix+k+".", out)
+		}
+	case []interface{}:
+		for i, child := range vv {
+			flattenValue(child, fmt.Sprintf("%s%d.", prefix, i), out)
+		}
+	case string:
+		out[strings.TrimSuffix(prefix, ".")] = vv
+	case nil:
+		// omit: nothing to flatten
+	default:
+		out[strings.TrimSuffix(prefix, ".")] = fmt.Sprintf("%v", vv)
+	}
+}
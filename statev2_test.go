@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsLegacyState(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"version 1", `{"version": 1}`, true},
+		{"version 3", `{"version": 3}`, true},
+		{"version 4", `{"version": 4}`, false},
+		{"version 5", `{"version": 5}`, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := IsLegacyState([]byte(c.data))
+			if err != nil {
+				t.Fatalf("IsLegacyState returned error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("IsLegacyState(%s) = %v, want %v", c.data, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLookupNestedString(t *testing.T) {
+	attrs := map[string]interface{}{
+		"name": "web",
+		"network_interface": []interface{}{
+			map[string]interface{}{
+				"access_config": []interface{}{
+					map[string]interface{}{"nat_ip": "1.2.3.4"},
+				},
+			},
+		},
+		"count": float64(3),
+	}
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"name", "web"},
+		{"network_interface.0.access_config.0.nat_ip", "1.2.3.4"},
+		{"network_interface.1.access_config.0.nat_ip", ""},
+		{"network_interface.0.access_config.5.nat_ip", ""},
+		{"missing", ""},
+		{"count", ""}, // not a string
+	}
+
+	for _, c := range cases {
+		if got := lookupNestedString(attrs, c.path); got != c.want {
+			t.Errorf("lookupNestedString(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestFlattenAttributes(t *testing.T) {
+	attrs := map[string]interface{}{
+		"name": "web",
+		"ip_configuration": []interface{}{
+			map[string]interface{}{"private_ip_address": "10.0.0.4"},
+			map[string]interface{}{"private_ip_address": "10.0.0.5"},
+		},
+		"enabled": true,
+		"ignored": nil,
+	}
+
+	want := map[string]string{
+		"name":                                  "web",
+		"enabled":                               "true",
+		"ip_configuration.0.private_ip_address": "10.0.0.4",
+		"ip_configuration.1.private_ip_address": "10.0.0.5",
+	}
+
+	got := flattenAttributes(attrs)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenAttributes = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseStateV2(t *testing.T) {
+	data := []byte(`{
+		"version": 4,
+		"resources": [
+			{
+				"mode": "managed",
+				"type": "aws_instance",
+				"name": "web",
+				"instances": [
+					{"index_key": 0, "attributes": {"id": "i-1", "public_ip": "1.1.1.1"}},
+					{"index_key": "frontend-a", "attributes": {"id": "i-2", "public_ip": "2.2.2.2"}}
+				]
+			},
+			{
+				"mode": "data",
+				"type": "aws_ami",
+				"name": "ubuntu",
+				"instances": [
+					{"attributes": {"id": "ami-1"}}
+				]
+			}
+		]
+	}`)
+
+	resources, err := ParseStateV2(data)
+	if err != nil {
+		t.Fatalf("ParseStateV2 returned error: %v", err)
+	}
+
+	if len(resources) != 2 {
+		t.Fatalf("got %d resources, want 2 (data resource should be skipped)", len(resources))
+	}
+
+	if got := resources[0].NameWithCounter(); got != "web.0" {
+		t.Errorf("resources[0].NameWithCounter() = %q, want %q", got, "web.0")
+	}
+	if got := resources[1].NameWithCounter(); got != "web.frontend-a" {
+		t.Errorf("resources[1].NameWithCounter() = %q, want %q", got, "web.frontend-a")
+	}
+}